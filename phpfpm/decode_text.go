@@ -0,0 +1,220 @@
+// Copyright © 2018 Enrico Stahn <enrico.stahn@gmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package phpfpm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// textTimeLayout is the format PHP-FPM uses for "start time" in the plain-text status page.
+const textTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// processBlockMarker separates the pool summary from each per-process block in the
+// plain-text status page, e.g. "************************".
+const processBlockMarker = "***"
+
+// decode unmarshals a status response into p, auto-detecting whether content holds the
+// JSON or plain-text status format from contentType and, failing that, its first byte.
+func decode(content []byte, contentType string, p *Pool) error {
+	if isJSON(content, contentType) {
+		return json.Unmarshal(content, p)
+	}
+
+	return decodeText(content, p)
+}
+
+// isJSON reports whether content should be treated as the JSON status format.
+func isJSON(content []byte, contentType string) bool {
+	if strings.Contains(contentType, "json") {
+		return true
+	}
+
+	if strings.Contains(contentType, "text/plain") || strings.Contains(contentType, "html") {
+		return false
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// decodeText parses the plain-text status page (QUERY_STRING without "json", or the
+// default no-query-string page) into p. The format is a "key: value" list describing
+// the pool, optionally followed by one block per process delimited by a line of asterisks.
+//
+// A "pool" line is required for the input to be accepted: without it there is no
+// positive signal that content is actually a PHP-FPM status page rather than, say, a
+// proxy error page or a truncated response, and silently treating it as a healthy,
+// idle pool would hide the failure from monitoring.
+func decodeText(content []byte, p *Pool) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	// Reset rather than append: p is typically reused across scrapes, and without this
+	// every call would pile its process block(s) onto whatever the previous scrape left
+	// behind, growing Processes without bound.
+	p.Processes = nil
+
+	var proc *PoolProcess
+	var sawPool bool
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, processBlockMarker) {
+			p.Processes = append(p.Processes, PoolProcess{})
+			proc = &p.Processes[len(p.Processes)-1]
+			continue
+		}
+
+		key, value, ok := splitTextLine(line)
+		if !ok {
+			continue
+		}
+
+		var err error
+		if proc != nil {
+			err = setProcessField(proc, key, value)
+		} else {
+			if key == "pool" {
+				sawPool = true
+			}
+			err = setPoolField(p, key, value)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if !sawPool {
+		return fmt.Errorf("decodeText: no \"pool\" line found, response is not a PHP-FPM status page")
+	}
+
+	return nil
+}
+
+// splitTextLine splits a "key: value" status line, trimming surrounding whitespace.
+func splitTextLine(line string) (key, value string, ok bool) {
+	idx := strings.Index(line, ":")
+	if idx < 0 {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:]), true
+}
+
+func setPoolField(p *Pool, key, value string) error {
+	switch key {
+	case "pool":
+		p.Name = value
+	case "process manager":
+		p.ProcessManager = value
+	case "start time":
+		t, err := time.Parse(textTimeLayout, value)
+		if err != nil {
+			return err
+		}
+		p.StartTime = timestamp(t)
+	case "start since":
+		return setInt64(&p.StartSince, value)
+	case "accepted conn":
+		return setInt64(&p.AcceptedConnections, value)
+	case "listen queue":
+		return setInt64(&p.ListenQueue, value)
+	case "max listen queue":
+		return setInt64(&p.MaxListenQueue, value)
+	case "listen queue len":
+		return setInt64(&p.ListenQueueLength, value)
+	case "idle processes":
+		return setInt64(&p.IdleProcesses, value)
+	case "active processes":
+		return setInt64(&p.ActiveProcesses, value)
+	case "total processes":
+		return setInt64(&p.TotalProcesses, value)
+	case "max active processes":
+		return setInt64(&p.MaxActiveProcesses, value)
+	case "max children reached":
+		return setInt64(&p.MaxChildrenReached, value)
+	case "slow requests":
+		return setInt64(&p.SlowRequests, value)
+	}
+
+	return nil
+}
+
+func setProcessField(proc *PoolProcess, key, value string) error {
+	switch key {
+	case "pid":
+		return setInt64(&proc.PID, value)
+	case "state":
+		proc.State = value
+	case "start time":
+		t, err := time.Parse(textTimeLayout, value)
+		if err != nil {
+			return err
+		}
+		proc.StartTime = t.Unix()
+	case "start since":
+		return setInt64(&proc.StartSince, value)
+	case "requests":
+		return setInt64(&proc.Requests, value)
+	case "request duration":
+		return setInt64(&proc.RequestDuration, value)
+	case "request method":
+		proc.RequestMethod = value
+	case "request uri":
+		proc.RequestURI = value
+	case "content length":
+		return setInt64(&proc.ContentLength, value)
+	case "user":
+		proc.User = value
+	case "script":
+		proc.Script = value
+	case "last request cpu":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		proc.LastRequestCPU = f
+	case "last request memory":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		proc.LastRequestMemory = n
+	}
+
+	return nil
+}
+
+func setInt64(dst *int64, value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q: %w", value, err)
+	}
+	*dst = n
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright © 2018 Enrico Stahn <enrico.stahn@gmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package phpfpm
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	content, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("reading fixture %v: %v", name, err)
+	}
+
+	return content
+}
+
+func TestDecodeText(t *testing.T) {
+	var p Pool
+
+	if err := decodeText(readFixture(t, "status.txt"), &p); err != nil {
+		t.Fatalf("decodeText: %v", err)
+	}
+
+	if p.Name != "www" {
+		t.Errorf("Name = %q, want %q", p.Name, "www")
+	}
+
+	if p.TotalProcesses != 2 {
+		t.Errorf("TotalProcesses = %v, want 2", p.TotalProcesses)
+	}
+
+	if len(p.Processes) != 2 {
+		t.Fatalf("len(Processes) = %v, want 2", len(p.Processes))
+	}
+
+	if p.Processes[0].PID != 1234 || p.Processes[0].State != "Running" {
+		t.Errorf("Processes[0] = %+v, want PID 1234 State Running", p.Processes[0])
+	}
+
+	if p.Processes[0].LastRequestCPU != 12.5 {
+		t.Errorf("Processes[0].LastRequestCPU = %v, want 12.5", p.Processes[0].LastRequestCPU)
+	}
+
+	if p.Processes[1].PID != 1235 || p.Processes[1].State != "Idle" {
+		t.Errorf("Processes[1] = %+v, want PID 1235 State Idle", p.Processes[1])
+	}
+}
+
+func TestDecodeText_Malformed(t *testing.T) {
+	var p Pool
+
+	err := decodeText(readFixture(t, "status_malformed.html"), &p)
+	if err == nil {
+		t.Fatal("decodeText returned nil error for a non-status response, want an error")
+	}
+}
+
+func TestDecode_JSON(t *testing.T) {
+	var p Pool
+
+	if err := decode(readFixture(t, "status_full.json"), "application/json", &p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if p.Name != "www" {
+		t.Errorf("Name = %q, want %q", p.Name, "www")
+	}
+
+	if len(p.Processes) != 2 {
+		t.Fatalf("len(Processes) = %v, want 2", len(p.Processes))
+	}
+}
+
+func TestDecode_JSONWithoutFull(t *testing.T) {
+	var p Pool
+
+	// No Content-Type, as returned by some FastCGI responses; format must be sniffed
+	// from the leading '{'.
+	if err := decode(readFixture(t, "status_nofull.json"), "", &p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if p.Name != "www" {
+		t.Errorf("Name = %q, want %q", p.Name, "www")
+	}
+
+	if p.Processes != nil {
+		t.Errorf("Processes = %+v, want nil (no \"full\" in the query string)", p.Processes)
+	}
+}
+
+func TestDecode_Malformed(t *testing.T) {
+	var p Pool
+
+	err := decode(readFixture(t, "status_malformed.html"), "text/html", &p)
+	if err == nil {
+		t.Fatal("decode returned nil error for a proxy error page, want an error")
+	}
+}
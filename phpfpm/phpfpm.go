@@ -15,15 +15,16 @@
 package phpfpm
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
-
-	"github.com/tomasen/fcgi_client"
 )
 
 // PoolProcessRequestIdle defines a process that is idle.
@@ -32,6 +33,12 @@ const PoolProcessRequestIdle string = "Idle"
 // PoolProcessRequestIdle defines a process that is active.
 const PoolProcessRequestActive string = "Running"
 
+// defaultTimeout is used when a Pool does not configure its own Timeout.
+const defaultTimeout = 3 * time.Second
+
+// defaultQueryString is used when a Pool does not configure its own QueryString.
+const defaultQueryString = "json&full"
+
 var log logger
 
 type logger interface {
@@ -43,15 +50,35 @@ type logger interface {
 	Errorf(string, ...interface{})
 }
 
-// PoolManager manages all configured Pools
-type PoolManager struct {
-	Pools []Pool `json:"pools"`
+// TLSConfig holds the TLS client settings used when a Pool is scraped over https://.
+type TLSConfig struct {
+	// CACert is the path to a PEM encoded CA bundle used to verify the server certificate.
+	CACert string
+	// ClientCert is the path to a PEM encoded client certificate presented to the server.
+	ClientCert string
+	// ClientKey is the path to the PEM encoded private key matching ClientCert.
+	ClientKey string
+	// InsecureSkipVerify disables server certificate verification.
+	InsecureSkipVerify bool
 }
 
-// Pool describes a single PHP-FPM pool that can be reached via a Socket or TCP address
+// Pool describes a single PHP-FPM pool that can be reached via a Socket, TCP or HTTP(S) address
 type Pool struct {
-	// The address of the pool, e.g. tcp://127.0.0.1:9000 or unix:///tmp/php-fpm.sock
-	Address             string        `json:"-"`
+	// The address of the pool, e.g. tcp://127.0.0.1:9000, unix:///tmp/php-fpm.sock or https://127.0.0.1/status
+	Address string `json:"-"`
+	// Timeout bounds how long Update waits for the pool to respond. Defaults to 3s.
+	Timeout time.Duration `json:"-"`
+	// QueryString is appended to the status request, e.g. "full" or "json&full".
+	// Defaults to "json&full". Omitting "json" causes the plain-text status format to be requested.
+	QueryString string `json:"-"`
+	// TLS configures the client used when Address uses the https:// scheme.
+	TLS *TLSConfig `json:"-"`
+	// Transport performs the FastCGI status request. Defaults to DefaultTransport.
+	Transport Transport `json:"-"`
+	// httpClientCache holds the *http.Client built by httpClient, lazily created on
+	// first use and reused by every subsequent Update so that TLS pools don't dial a
+	// fresh http.Transport (and leak its idle-conn goroutines) on every scrape.
+	httpClientCache     *http.Client  `json:"-"`
 	ScrapeError         error         `json:"-"`
 	ScrapeFailures      int64         `json:"-"`
 	Name                string        `json:"pool"`
@@ -88,79 +115,180 @@ type PoolProcess struct {
 	LastRequestMemory int     `json:"last request memory"`
 }
 
-// Add will add a pool to the pool manager based on the given URI.
-func (pm *PoolManager) Add(uri string) Pool {
-	p := Pool{Address: uri}
-	pm.Pools = append(pm.Pools, p)
-	return p
-}
+// Update will connect to PHP-FPM and retrieve the latest data for the pool, honouring
+// ctx's deadline and cancellation.
+//
+// Address is parsed to decide how the pool is reached: http:// and https:// are
+// fetched via net/http, everything else (tcp://, unix://) is fetched via Transport.
+func (p *Pool) Update(ctx context.Context) (err error) {
+	p.ScrapeError = nil
 
-// Update will run the pool.Update() method concurrently on all Pools.
-func (pm *PoolManager) Update() (err error) {
-	wg := &sync.WaitGroup{}
+	uri, err := url.Parse(p.Address)
+	if err != nil {
+		return p.error(err)
+	}
 
-	started := time.Now()
+	var content []byte
+	var contentType string
 
-	for idx := range pm.Pools {
-		wg.Add(1)
-		go func(p *Pool) {
-			defer wg.Done()
-			p.Update()
-		}(&pm.Pools[idx])
+	switch uri.Scheme {
+	case "http", "https":
+		content, contentType, err = p.updateHTTP(ctx, uri)
+	default:
+		content, contentType, err = p.updateFastCGI(ctx, uri)
 	}
 
-	wg.Wait()
+	if err != nil {
+		return p.error(err)
+	}
 
-	ended := time.Now()
+	log.Debugf("Pool[%v]: %v", p.Address, string(content))
 
-	log.Debugf("Updated %v pool(s) in %v", len(pm.Pools), ended.Sub(started))
+	if err = decode(content, contentType, p); err != nil {
+		return p.error(err)
+	}
 
 	return nil
 }
 
-// Update will connect to PHP-FPM and retrieve the latest data for the pool.
-func (p *Pool) Update() (err error) {
-	p.ScrapeError = nil
+// timeout returns the configured Timeout, falling back to defaultTimeout.
+func (p *Pool) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return defaultTimeout
+}
+
+// queryString returns the configured QueryString, falling back to defaultQueryString.
+func (p *Pool) queryString() string {
+	if p.QueryString != "" {
+		return p.QueryString
+	}
+	return defaultQueryString
+}
 
+// updateFastCGI retrieves the status page via the pool's Transport, falling back to
+// DefaultTransport if none is configured.
+func (p *Pool) updateFastCGI(ctx context.Context, uri *url.URL) ([]byte, string, error) {
 	env := map[string]string{
 		"SCRIPT_FILENAME": "/status",
 		"SCRIPT_NAME":     "/status",
 		"SERVER_SOFTWARE": "go / php-fpm_exporter",
 		"REMOTE_ADDR":     "127.0.0.1",
-		"QUERY_STRING":    "json&full",
+		"QUERY_STRING":    p.queryString(),
 	}
 
-	uri, err := url.Parse(p.Address)
+	address := uri.Hostname() + ":" + uri.Port()
+	if uri.Scheme == "unix" {
+		address = uri.Path
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	transport := p.Transport
+	if transport == nil {
+		transport = DefaultTransport
+	}
+
+	content, err := transport.Get(ctx, address, env)
 	if err != nil {
-		return p.error(err)
+		return nil, "", err
+	}
+
+	return content, "", nil
+}
+
+// updateHTTP retrieves the status page from a PHP-FPM status endpoint exposed behind
+// a web server (e.g. nginx or Apache), instead of dialing the FastCGI socket directly.
+func (p *Pool) updateHTTP(ctx context.Context, uri *url.URL) ([]byte, string, error) {
+	q := uri.Query()
+	for _, part := range strings.Split(p.queryString(), "&") {
+		if part != "" {
+			q.Set(part, "")
+		}
 	}
+	reqURL := *uri
+	reqURL.RawQuery = q.Encode()
 
-	fcgi, err := fcgiclient.DialTimeout(uri.Scheme, uri.Hostname()+":"+uri.Port(), time.Duration(3)*time.Second)
+	client, err := p.httpClient()
 	if err != nil {
-		return p.error(err)
+		return nil, "", err
 	}
 
-	defer fcgi.Close()
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
 
-	resp, err := fcgi.Get(env)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
 	if err != nil {
-		return p.error(err)
+		return nil, "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status code %v from %v", resp.StatusCode, reqURL.String())
+	}
+
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return p.error(err)
+		return nil, "", err
 	}
 
-	log.Debugf("Pool[%v]: %v", p.Address, string(content))
+	return content, resp.Header.Get("Content-Type"), nil
+}
 
-	if err = json.Unmarshal(content, &p); err != nil {
-		return p.error(err)
+// httpClient returns the http.Client used to scrape an http(s):// Address, applying
+// the pool's Timeout and, for https://, its TLS configuration. The client is built
+// once and cached on the Pool: when TLS is set it wraps its own *http.Transport, and
+// rebuilding one on every scrape would leak its idle-connection reaper goroutine.
+func (p *Pool) httpClient() (*http.Client, error) {
+	if p.httpClientCache != nil {
+		return p.httpClientCache, nil
 	}
 
-	return nil
+	client := &http.Client{Timeout: p.timeout()}
+
+	if p.TLS == nil {
+		p.httpClientCache = client
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: p.TLS.InsecureSkipVerify} // nolint:gosec
+
+	if p.TLS.CACert != "" {
+		caCert, err := ioutil.ReadFile(p.TLS.CACert)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA certificate %v", p.TLS.CACert)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if p.TLS.ClientCert != "" || p.TLS.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(p.TLS.ClientCert, p.TLS.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+
+	p.httpClientCache = client
+
+	return client, nil
 }
 
 func (p *Pool) error(err error) error {
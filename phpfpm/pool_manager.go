@@ -0,0 +1,300 @@
+// Copyright © 2018 Enrico Stahn <enrico.stahn@gmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package phpfpm
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PoolManager manages all configured Pools, scraping them concurrently and safely
+// supporting pools being added or removed while scrapes are in flight.
+type PoolManager struct {
+	// MaxConcurrency caps the number of Pools dialed concurrently during Update.
+	// Zero (the default) means unbounded.
+	MaxConcurrency int
+
+	mu    sync.RWMutex
+	pools []Pool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// MarshalJSON renders the PoolManager's pools as {"pools": [...]}, matching the
+// historical JSON representation.
+func (pm *PoolManager) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Pools []Pool `json:"pools"`
+	}{Pools: pm.List()})
+}
+
+// Add will add a pool to the pool manager based on the given URI.
+func (pm *PoolManager) Add(uri string) Pool {
+	p := Pool{Address: uri}
+
+	pm.mu.Lock()
+	pm.pools = append(pm.pools, p)
+	pm.mu.Unlock()
+
+	return p
+}
+
+// AddGlob expands a shell glob pattern, e.g. "/var/run/php*-fpm*.sock" or
+// "/run/php/*.sock", and adds a Pool for each matching unix socket. It returns the
+// pools added, skipping sockets that are already known to the manager.
+func (pm *PoolManager) AddGlob(pattern string) ([]Pool, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	added := make([]Pool, 0, len(matches))
+
+	for _, match := range matches {
+		uri := "unix://" + match
+
+		if _, ok := pm.Get(uri); ok {
+			continue
+		}
+
+		added = append(added, pm.Add(uri))
+	}
+
+	return added, nil
+}
+
+// ReloadGlob re-expands pattern, adding pools for sockets that have newly appeared
+// and removing pools whose unix socket has disappeared. Intended to be called again
+// from a SIGHUP handler when pools come and go on multi-tenant hosts.
+func (pm *PoolManager) ReloadGlob(pattern string) (added []Pool, removed []string, err error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		current["unix://"+match] = true
+	}
+
+	added, err = pm.AddGlob(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, p := range pm.List() {
+		if current[p.Address] || !isGlobMatch(pattern, p.Address) {
+			continue
+		}
+
+		pm.Remove(p.Address)
+		removed = append(removed, p.Address)
+	}
+
+	return added, removed, nil
+}
+
+// isGlobMatch reports whether uri is a unix socket address whose path was produced
+// by (or would be produced by) expanding pattern.
+func isGlobMatch(pattern, uri string) bool {
+	const prefix = "unix://"
+	if len(uri) <= len(prefix) || uri[:len(prefix)] != prefix {
+		return false
+	}
+
+	ok, err := filepath.Match(pattern, uri[len(prefix):])
+	return err == nil && ok
+}
+
+// Remove removes the pool with the given address, reporting whether it was found.
+func (pm *PoolManager) Remove(uri string) bool {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	for idx := range pm.pools {
+		if pm.pools[idx].Address == uri {
+			pm.pools = append(pm.pools[:idx], pm.pools[idx+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns a copy of the pool with the given address, if any.
+func (pm *PoolManager) Get(uri string) (Pool, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	for idx := range pm.pools {
+		if pm.pools[idx].Address == uri {
+			return clonePool(pm.pools[idx]), true
+		}
+	}
+
+	return Pool{}, false
+}
+
+// List returns a copy of all pools currently known to the manager.
+func (pm *PoolManager) List() []Pool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	pools := make([]Pool, len(pm.pools))
+	for idx := range pm.pools {
+		pools[idx] = clonePool(pm.pools[idx])
+	}
+
+	return pools
+}
+
+// clonePool returns p with its Processes slice deep-copied, so the returned Pool
+// shares no backing array with p. Get and List hand Pools to callers that read them
+// concurrently with later Updates; Update itself also snapshots through List before
+// scraping. Without this, decode (called on the live scrape's copy, outside any lock)
+// can reuse a Processes backing array still aliased by a previously returned Pool,
+// racing a concurrent reader against the write.
+func clonePool(p Pool) Pool {
+	if p.Processes != nil {
+		processes := make([]PoolProcess, len(p.Processes))
+		copy(processes, p.Processes)
+		p.Processes = processes
+	}
+
+	return p
+}
+
+// Update will run the pool.Update() method concurrently on all Pools, bounded by
+// MaxConcurrency in-flight scrapes and honouring ctx's deadline and cancellation.
+//
+// Pools are snapshotted before scraping and merged back by Address afterwards, so an
+// Add or Remove racing with an in-flight Update cannot corrupt the live pool list.
+func (pm *PoolManager) Update(ctx context.Context) (err error) {
+	pools := pm.List()
+
+	var sem chan struct{}
+	if pm.MaxConcurrency > 0 {
+		sem = make(chan struct{}, pm.MaxConcurrency)
+	}
+
+	wg := &sync.WaitGroup{}
+
+	started := time.Now()
+
+	for idx := range pools {
+		wg.Add(1)
+
+		go func(p *Pool) {
+			defer wg.Done()
+
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+
+			p.Update(ctx)
+		}(&pools[idx])
+	}
+
+	wg.Wait()
+
+	pm.merge(pools)
+
+	ended := time.Now()
+
+	log.Debugf("Updated %v pool(s) in %v", len(pools), ended.Sub(started))
+
+	return nil
+}
+
+// merge writes scraped pool state back into the live pool list, matching by Address.
+// Pools removed while the scrape was in flight are silently dropped; pools added in
+// the meantime are left untouched and picked up on the next Update.
+func (pm *PoolManager) merge(scraped []Pool) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	for i := range scraped {
+		for j := range pm.pools {
+			if pm.pools[j].Address == scraped[i].Address {
+				pm.pools[j] = scraped[i]
+				break
+			}
+		}
+	}
+}
+
+// Start runs Update on a background goroutine every interval, jittered by up to 10%
+// of interval to avoid every pool manager in a fleet scraping in lockstep. It returns
+// immediately; call Stop (or cancel ctx) to stop scraping. Start is a no-op if interval
+// is not positive.
+func (pm *PoolManager) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		log.Errorf("PoolManager.Start: interval must be positive, got %v", interval)
+		return
+	}
+
+	pm.mu.Lock()
+	if pm.stop != nil {
+		pm.mu.Unlock()
+		return
+	}
+	pm.stop = make(chan struct{})
+	pm.done = make(chan struct{})
+	stop, done := pm.stop, pm.done
+	pm.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		for {
+			var jitter time.Duration
+			if bound := int64(interval) / 10; bound > 0 {
+				jitter = time.Duration(rand.Int63n(bound)) // nolint:gosec
+			}
+			timer := time.NewTimer(interval + jitter)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+				pm.Update(ctx)
+			}
+		}
+	}()
+}
+
+// Stop stops a scheduler started with Start and waits for it to finish.
+func (pm *PoolManager) Stop() {
+	pm.mu.Lock()
+	stop, done := pm.stop, pm.done
+	pm.stop, pm.done = nil, nil
+	pm.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+}
@@ -0,0 +1,98 @@
+// Copyright © 2018 Enrico Stahn <enrico.stahn@gmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package phpfpm
+
+import "sort"
+
+// ProcessFieldStats summarises the values of a single per-process field reported
+// across all currently known processes of a Pool.
+type ProcessFieldStats struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+	P50   float64
+	P90   float64
+	P99   float64
+}
+
+// ProcessStats aggregates the per-process fields PHP-FPM reports for each process of
+// a Pool, for publishing as Prometheus summaries/histograms.
+type ProcessStats struct {
+	RequestDuration   ProcessFieldStats
+	LastRequestCPU    ProcessFieldStats
+	LastRequestMemory ProcessFieldStats
+	ByState           map[string]int64
+}
+
+// CalculateProcessStats aggregates RequestDuration, LastRequestCPU and LastRequestMemory
+// across the processes currently reported by p, alongside a count of processes per state.
+func CalculateProcessStats(p Pool) ProcessStats {
+	durations := make([]float64, 0, len(p.Processes))
+	cpu := make([]float64, 0, len(p.Processes))
+	memory := make([]float64, 0, len(p.Processes))
+	byState := map[string]int64{}
+
+	for idx := range p.Processes {
+		proc := p.Processes[idx]
+		durations = append(durations, float64(proc.RequestDuration))
+		cpu = append(cpu, proc.LastRequestCPU)
+		memory = append(memory, float64(proc.LastRequestMemory))
+		byState[proc.State]++
+	}
+
+	return ProcessStats{
+		RequestDuration:   calculateFieldStats(durations),
+		LastRequestCPU:    calculateFieldStats(cpu),
+		LastRequestMemory: calculateFieldStats(memory),
+		ByState:           byState,
+	}
+}
+
+// calculateFieldStats computes count/sum/min/max/p50/p90/p99 over values.
+func calculateFieldStats(values []float64) ProcessFieldStats {
+	stats := ProcessFieldStats{Count: int64(len(values))}
+
+	if len(values) == 0 {
+		return stats
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+
+	for _, v := range sorted {
+		stats.Sum += v
+	}
+
+	stats.P50 = percentile(sorted, 0.50)
+	stats.P90 = percentile(sorted, 0.90)
+	stats.P99 = percentile(sorted, 0.99)
+
+	return stats
+}
+
+// percentile returns the value at the given percentile (0..1) of sorted, which must
+// already be sorted in ascending order and non-empty.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
@@ -0,0 +1,96 @@
+// Copyright © 2018 Enrico Stahn <enrico.stahn@gmail.com>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package phpfpm
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/tomasen/fcgi_client"
+)
+
+// Transport retrieves a PHP-FPM status page for a single pool, decoupling Pool.Update
+// from any particular FastCGI client implementation. This makes it possible to stub
+// out FastCGI in tests, or swap in a client that supports connection pooling or keep-alive.
+type Transport interface {
+	// Get retrieves the status page at address (a unix socket path or a "host:port" TCP
+	// address) using env as the FastCGI request parameters, honouring ctx's deadline
+	// and cancellation.
+	Get(ctx context.Context, address string, env map[string]string) ([]byte, error)
+}
+
+// DefaultTransport is used by Pool.Update when Pool.Transport is nil.
+var DefaultTransport Transport = &fcgiTransport{}
+
+// fcgiTransport is the default Transport, wrapping github.com/tomasen/fcgi_client.
+type fcgiTransport struct{}
+
+func (t *fcgiTransport) Get(ctx context.Context, address string, env map[string]string) ([]byte, error) {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+
+	timeout := defaultTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); d > 0 {
+			timeout = d
+		}
+	}
+
+	fcgi, err := fcgiclient.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fcgi.Close()
+
+	// fcgi_client has no notion of a context: once dialed, fcgi.Get blocks on the
+	// connection until the remote side responds or the connection itself errors.
+	// Race ctx.Done() against the request and close the connection to unblock it,
+	// so a hung FPM worker or caller cancellation actually aborts the request
+	// instead of just bounding the dial.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			fcgi.Close()
+		case <-done:
+		}
+	}()
+
+	resp, err := fcgi.Get(env)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	return content, nil
+}